@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/cecobask/imdb-trakt-sync/pkg/config"
+	"github.com/cecobask/imdb-trakt-sync/pkg/logger"
+	"github.com/cecobask/imdb-trakt-sync/pkg/syncer"
+	"go.uber.org/zap"
+)
+
+// maxConcurrentProfiles bounds how many profiles run at the same time, so a
+// config file listing many accounts doesn't overwhelm the IMDb/Trakt APIs.
+const maxConcurrentProfiles = 4
+
+func main() {
+	configPath := flag.String("config", os.Getenv(config.EnvVarKeyConfigPath), "path to a multi-profile config file")
+	flag.Parse()
+	log := logger.NewLogger()
+	profiles, err := resolveProfiles(*configPath)
+	if err != nil {
+		log.Fatal("failure resolving profiles", zap.Error(err))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentProfiles)
+	for i := range profiles {
+		profile := profiles[i]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			runProfile(ctx, profile)
+		}()
+	}
+	wg.Wait()
+}
+
+// resolveProfiles loads every profile from configPath, falling back to a
+// single profile assembled from the legacy environment variables when no
+// config file is given.
+func resolveProfiles(configPath string) ([]config.Profile, error) {
+	if configPath == "" {
+		return []config.Profile{config.ProfileFromEnv()}, nil
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Profiles, nil
+}
+
+// runProfile runs a single profile to completion, scheduling repeated syncs
+// when the profile defines a schedule and otherwise performing a one-shot
+// sync. It never terminates the process: multiple profiles run as goroutines
+// in the same process, so one profile's failure must not abort the rest.
+func runProfile(ctx context.Context, profile config.Profile) {
+	s := syncer.NewSyncer(profile)
+	log := logger.NewLogger().With(zap.String("profile", profile.Name))
+	if profile.Schedule != "" {
+		if err := s.RunScheduled(ctx); err != nil {
+			log.Error("failure running scheduled sync", zap.Error(err))
+		}
+		return
+	}
+	report := s.RunOnce()
+	if !report.Success() {
+		log.Error("failure running sync", zap.Strings("errors", report.Errors))
+		return
+	}
+	log.Info("successfully synced trakt with imdb")
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store is a pluggable key/value cache with per-entry TTLs. Implementations
+// must be safe for concurrent use.
+type Store interface {
+	// Get looks up key and, if present and not expired, unmarshals its value
+	// into out and returns true. A missing or expired entry returns false
+	// with a nil error.
+	Get(key string, out interface{}) (bool, error)
+	// Set stores value under key, expiring it after ttl.
+	Set(key string, value interface{}, ttl time.Duration) error
+	// Delete removes key, if present.
+	Delete(key string) error
+}
+
+type fileEntry struct {
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt time.Time       `json:"expiresAt"`
+}
+
+// FileStore is a Store backed by one JSON file per key on disk.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore creates a FileStore rooted at dir, creating it if necessary.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failure creating cache directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (fs *FileStore) path(key string) string {
+	return filepath.Join(fs.dir, url.QueryEscape(key)+".json")
+}
+
+func (fs *FileStore) Get(key string, out interface{}) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	data, err := os.ReadFile(fs.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failure reading cache entry %s: %w", key, err)
+	}
+	var entry fileEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return false, fmt.Errorf("failure unmarshalling cache entry %s: %w", key, err)
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		_ = os.Remove(fs.path(key))
+		return false, nil
+	}
+	if err = json.Unmarshal(entry.Value, out); err != nil {
+		return false, fmt.Errorf("failure unmarshalling cached value for %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (fs *FileStore) Set(key string, value interface{}, ttl time.Duration) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failure marshalling cache value for %s: %w", key, err)
+	}
+	data, err := json.Marshal(fileEntry{
+		Value:     raw,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("failure marshalling cache entry %s: %w", key, err)
+	}
+	if err = os.WriteFile(fs.path(key), data, 0o644); err != nil {
+		return fmt.Errorf("failure writing cache entry %s: %w", key, err)
+	}
+	return nil
+}
+
+func (fs *FileStore) Delete(key string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := os.Remove(fs.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failure deleting cache entry %s: %w", key, err)
+	}
+	return nil
+}
+
+// Key builds a cache key from the given parts, keeping entries unique per
+// user, list and resource type.
+func Key(parts ...string) string {
+	return filepath.Join(parts...)
+}
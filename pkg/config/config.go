@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvVarKeyConfigPath points at a multi-profile config file, as an
+	// alternative to the --config flag.
+	EnvVarKeyConfigPath = "CONFIG_PATH"
+
+	envVarKeyCookieAtMain   = "IMDB_COOKIE_AT_MAIN"
+	envVarKeyCookieUbidMain = "IMDB_COOKIE_UBID_MAIN"
+	envVarKeyListIds        = "IMDB_LIST_IDS"
+	envVarKeyUserId         = "IMDB_USER_ID"
+	envVarKeyClientId       = "TRAKT_CLIENT_ID"
+	envVarKeyClientSecret   = "TRAKT_CLIENT_SECRET"
+	envVarKeyPassword       = "TRAKT_PASSWORD"
+	envVarKeyUsername       = "TRAKT_USERNAME"
+	envVarKeySyncMode       = "SYNC_MODE"
+	envVarKeyConflictMode   = "CONFLICT_STRATEGY"
+	envVarKeySyncSchedule   = "SYNC_SCHEDULE"
+
+	// defaultProfileName is used for the implicit profile assembled by
+	// ProfileFromEnv, since a single-profile environment has no natural
+	// name of its own.
+	defaultProfileName = "default"
+)
+
+// Profile holds everything needed to sync a single IMDb/Trakt account pair.
+// A Config lists one Profile per household member or hosted user.
+type Profile struct {
+	Name               string   `yaml:"name"`
+	ImdbCookieAtMain   string   `yaml:"imdbCookieAtMain"`
+	ImdbCookieUbidMain string   `yaml:"imdbCookieUbidMain"`
+	ImdbUserId         string   `yaml:"imdbUserId"`
+	ImdbListIds        []string `yaml:"imdbListIds"`
+	TraktClientId      string   `yaml:"traktClientId"`
+	TraktClientSecret  string   `yaml:"traktClientSecret"`
+	TraktUsername      string   `yaml:"traktUsername"`
+	TraktPassword      string   `yaml:"traktPassword"`
+	SyncMode           string   `yaml:"syncMode"`
+	ConflictStrategy   string   `yaml:"conflictStrategy"`
+	Schedule           string   `yaml:"schedule"`
+}
+
+// Config is the top-level multi-profile configuration file.
+type Config struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// Load reads and parses the config file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failure reading config file %s: %w", path, err)
+	}
+	var cfg Config
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failure parsing config file %s: %w", path, err)
+	}
+	if len(cfg.Profiles) == 0 {
+		return nil, fmt.Errorf("config file %s defines no profiles", path)
+	}
+	for i := range cfg.Profiles {
+		if err = cfg.Profiles[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid profile at index %d: %w", i, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// Validate checks that a Profile has everything required to authenticate
+// against both IMDb and Trakt.
+func (p Profile) Validate() error {
+	var missing []string
+	if p.Name == "" {
+		missing = append(missing, "name")
+	}
+	if p.ImdbCookieAtMain == "" {
+		missing = append(missing, "imdbCookieAtMain")
+	}
+	if p.ImdbCookieUbidMain == "" {
+		missing = append(missing, "imdbCookieUbidMain")
+	}
+	if p.TraktClientId == "" {
+		missing = append(missing, "traktClientId")
+	}
+	if p.TraktClientSecret == "" {
+		missing = append(missing, "traktClientSecret")
+	}
+	if p.TraktUsername == "" {
+		missing = append(missing, "traktUsername")
+	}
+	if p.TraktPassword == "" {
+		missing = append(missing, "traktPassword")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields: %v", missing)
+	}
+	return nil
+}
+
+// ProfileFromEnv assembles a single Profile from the legacy, single-account
+// environment variables, for backward compatibility with configurations
+// that predate the multi-profile config file.
+func ProfileFromEnv() Profile {
+	var listIds []string
+	// "all" (and unset) is the documented sentinel for "scrape every IMDb
+	// list rather than syncing a fixed set" - leave ImdbListIds empty so
+	// syncer falls back to ListsScrape.
+	if raw := os.Getenv(envVarKeyListIds); raw != "" && raw != "all" {
+		listIds = strings.Split(raw, ",")
+	}
+	return Profile{
+		Name:               defaultProfileName,
+		ImdbCookieAtMain:   os.Getenv(envVarKeyCookieAtMain),
+		ImdbCookieUbidMain: os.Getenv(envVarKeyCookieUbidMain),
+		ImdbUserId:         os.Getenv(envVarKeyUserId),
+		ImdbListIds:        listIds,
+		TraktClientId:      os.Getenv(envVarKeyClientId),
+		TraktClientSecret:  os.Getenv(envVarKeyClientSecret),
+		TraktUsername:      os.Getenv(envVarKeyUsername),
+		TraktPassword:      os.Getenv(envVarKeyPassword),
+		SyncMode:           os.Getenv(envVarKeySyncMode),
+		ConflictStrategy:   os.Getenv(envVarKeyConflictMode),
+		Schedule:           os.Getenv(envVarKeySyncSchedule),
+	}
+}
@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	// OnSuccess and OnFailure select when a Notifier should be invoked,
+	// configured via the NOTIFY_ON env var as a comma separated list.
+	OnSuccess = "success"
+	OnFailure = "failure"
+)
+
+// RunReport summarises a single Syncer.Run invocation for reporting through
+// a Notifier.
+type RunReport struct {
+	Started          time.Time
+	Finished         time.Time
+	ListItemsAdded   int
+	ListItemsRemoved int
+	WatchlistAdded   int
+	WatchlistRemoved int
+	RatingsAdded     int
+	RatingsRemoved   int
+	HistoryAdded     int
+	HistoryRemoved   int
+	Errors           []string
+}
+
+// Success reports whether the run completed without errors.
+func (r RunReport) Success() bool {
+	return len(r.Errors) == 0
+}
+
+// Duration returns how long the run took.
+func (r RunReport) Duration() time.Duration {
+	return r.Finished.Sub(r.Started)
+}
+
+// Summary renders the report as a short human-readable message shared by
+// every Notifier implementation.
+func (r RunReport) Summary() string {
+	var b strings.Builder
+	if r.Success() {
+		fmt.Fprintf(&b, "imdb-trakt-sync completed successfully in %s\n", r.Duration())
+	} else {
+		fmt.Fprintf(&b, "imdb-trakt-sync failed after %s\n", r.Duration())
+	}
+	fmt.Fprintf(&b, "lists: +%d/-%d, watchlist: +%d/-%d, ratings: +%d/-%d, history: +%d/-%d\n",
+		r.ListItemsAdded, r.ListItemsRemoved,
+		r.WatchlistAdded, r.WatchlistRemoved,
+		r.RatingsAdded, r.RatingsRemoved,
+		r.HistoryAdded, r.HistoryRemoved,
+	)
+	for _, e := range r.Errors {
+		fmt.Fprintf(&b, "error: %s\n", e)
+	}
+	return b.String()
+}
+
+// Notifier dispatches a RunReport to an external sink.
+type Notifier interface {
+	Notify(report RunReport) error
+}
+
+// DiscordNotifier posts a RunReport summary to a Discord webhook.
+type DiscordNotifier struct {
+	WebhookUrl string
+}
+
+func (n *DiscordNotifier) Notify(report RunReport) error {
+	body, err := json.Marshal(map[string]string{"content": report.Summary()})
+	if err != nil {
+		return fmt.Errorf("failure marshalling discord payload: %w", err)
+	}
+	return postJson(n.WebhookUrl, body)
+}
+
+// SlackNotifier posts a RunReport summary to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookUrl string
+}
+
+func (n *SlackNotifier) Notify(report RunReport) error {
+	body, err := json.Marshal(map[string]string{"text": report.Summary()})
+	if err != nil {
+		return fmt.Errorf("failure marshalling slack payload: %w", err)
+	}
+	return postJson(n.WebhookUrl, body)
+}
+
+// TelegramNotifier sends a RunReport summary via a Telegram bot.
+type TelegramNotifier struct {
+	BotToken string
+	ChatId   string
+}
+
+func (n *TelegramNotifier) Notify(report RunReport) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", n.BotToken)
+	body, err := json.Marshal(map[string]string{
+		"chat_id": n.ChatId,
+		"text":    report.Summary(),
+	})
+	if err != nil {
+		return fmt.Errorf("failure marshalling telegram payload: %w", err)
+	}
+	return postJson(url, body)
+}
+
+// SmtpNotifier emails a RunReport summary through a generic SMTP server.
+type SmtpNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n *SmtpNotifier) Notify(report RunReport) error {
+	addr := fmt.Sprintf("%s:%s", n.Host, n.Port)
+	subject := "imdb-trakt-sync run succeeded"
+	if !report.Success() {
+		subject = "imdb-trakt-sync run failed"
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
+		n.From, strings.Join(n.To, ","), subject, report.Summary())
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+	if err := smtp.SendMail(addr, auth, n.From, n.To, []byte(message)); err != nil {
+		return fmt.Errorf("failure sending smtp notification: %w", err)
+	}
+	return nil
+}
+
+func postJson(url string, body []byte) error {
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failure posting notification to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("notification endpoint %s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// ShouldNotify reports whether a notifier configured to fire on the events
+// in notifyOn (a comma separated list of OnSuccess/OnFailure) should run for
+// the given report.
+func ShouldNotify(notifyOn string, report RunReport) bool {
+	events := strings.Split(notifyOn, ",")
+	for i := range events {
+		event := strings.TrimSpace(events[i])
+		if report.Success() && event == OnSuccess {
+			return true
+		}
+		if !report.Success() && event == OnFailure {
+			return true
+		}
+	}
+	return false
+}
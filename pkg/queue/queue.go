@@ -0,0 +1,304 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// JobStatus describes where a Job is in its lifecycle.
+type JobStatus string
+
+const (
+	JobStatusPending JobStatus = "pending"
+	JobStatusRunning JobStatus = "running"
+	JobStatusDone    JobStatus = "done"
+	JobStatusFailed  JobStatus = "failed"
+)
+
+// Job is a single unit of work submitted to a Queue. Name identifies the
+// operation for logging and status reporting purposes.
+type Job struct {
+	Id          string          `json:"id"`
+	Name        string          `json:"name"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempt     int             `json:"attempt"`
+	MaxAttempts int             `json:"maxAttempts"`
+	Status      JobStatus       `json:"status"`
+	LastError   string          `json:"lastError,omitempty"`
+}
+
+// Handler executes a job. A RetryableError return causes the queue to retry
+// the job with exponential backoff, up to MaxAttempts; any other error fails
+// the job permanently.
+type Handler func(job Job) error
+
+// RetryableError wraps an error that should be retried rather than treated
+// as a terminal failure.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Status is a point-in-time snapshot of queue health, suitable for logging
+// or serialising to the status file.
+type Status struct {
+	Depth       int `json:"depth"`
+	FailedCount int `json:"failedCount"`
+}
+
+// Queue is an in-process job queue processed by a fixed pool of workers,
+// with exponential backoff and jitter between retries. Queue state is
+// persisted to disk so a crashed or killed run can resume unfinished jobs.
+type Queue struct {
+	mu          sync.Mutex
+	jobs        chan Job
+	done        chan struct{}
+	closeOnce   sync.Once
+	workerCount int
+	handler     Handler
+	statePath   string
+	statusPath  string
+	state       map[string]Job
+	failedCount int
+	wg          sync.WaitGroup
+}
+
+// NewQueue creates a Queue with workerCount workers backed by statePath for
+// persistence. If statePath already contains pending jobs from a previous
+// run, they are loaded and resumed.
+func NewQueue(workerCount int, statePath string, handler Handler) (*Queue, error) {
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	q := &Queue{
+		jobs:        make(chan Job, 1024),
+		workerCount: workerCount,
+		handler:     handler,
+		statePath:   statePath,
+		statusPath:  statePath + ".status",
+		state:       make(map[string]Job),
+	}
+	if err := q.load(); err != nil {
+		return nil, fmt.Errorf("failure loading queue state from %s: %w", statePath, err)
+	}
+	for _, job := range q.state {
+		if job.Status == JobStatusPending || job.Status == JobStatusRunning {
+			job.Status = JobStatusPending
+			q.jobs <- job
+		}
+	}
+	return q, nil
+}
+
+func (q *Queue) load() error {
+	data, err := os.ReadFile(q.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &q.state)
+}
+
+func (q *Queue) persist() error {
+	data, err := json.MarshalIndent(q.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failure marshalling queue state: %w", err)
+	}
+	return os.WriteFile(q.statePath, data, 0o644)
+}
+
+// Enqueue submits a job for processing, defaulting MaxAttempts to 5 when
+// unset.
+func (q *Queue) Enqueue(job Job) error {
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = 5
+	}
+	job.Status = JobStatusPending
+	q.mu.Lock()
+	q.state[job.Id] = job
+	err := q.persist()
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	q.jobs <- job
+	return nil
+}
+
+// Start launches the worker pool and returns immediately; Close stops it.
+// Each call opens a fresh done channel and a fresh set of workers, so a
+// Queue can be cycled through repeated Start/Close pairs — one per
+// scheduled tick — without the new workers selecting on a done channel an
+// earlier Close already closed and returning immediately.
+func (q *Queue) Start() {
+	q.mu.Lock()
+	q.done = make(chan struct{})
+	q.closeOnce = sync.Once{}
+	done := q.done
+	q.mu.Unlock()
+	for i := 0; i < q.workerCount; i++ {
+		q.wg.Add(1)
+		go q.work(done)
+	}
+}
+
+// Close signals the current cycle's workers to stop retrying and waits for
+// in-flight jobs to finish their current attempt. A job that was about to
+// retry is left persisted as pending instead of being resent, so a new Queue
+// loading the same statePath resumes it; a later Start on this Queue does
+// not, since the job is no longer in its jobs channel. Jobs still waiting in
+// the channel are not drained by Close and are picked up by a later Start.
+// The jobs channel itself is never closed, since a worker asleep in backoff
+// could otherwise race a send against it and panic.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	done := q.done
+	once := &q.closeOnce
+	q.mu.Unlock()
+	once.Do(func() { close(done) })
+	q.wg.Wait()
+}
+
+func (q *Queue) work(done chan struct{}) {
+	defer q.wg.Done()
+	for {
+		select {
+		case job, ok := <-q.jobs:
+			if !ok {
+				return
+			}
+			q.process(job, done)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (q *Queue) process(job Job, done chan struct{}) {
+	job.Attempt++
+	job.Status = JobStatusRunning
+	q.setState(job)
+	err := q.handler(job)
+	if err == nil {
+		job.Status = JobStatusDone
+		job.LastError = ""
+		q.finalize(job)
+		return
+	}
+	var retryable *RetryableError
+	if !asRetryable(err, &retryable) || job.Attempt >= job.MaxAttempts {
+		job.Status = JobStatusFailed
+		job.LastError = err.Error()
+		q.mu.Lock()
+		q.failedCount++
+		q.mu.Unlock()
+		q.finalize(job)
+		return
+	}
+	job.Status = JobStatusPending
+	job.LastError = err.Error()
+	q.setState(job)
+	select {
+	case <-done:
+		return
+	case <-time.After(backoff(job.Attempt)):
+	}
+	select {
+	case q.jobs <- job:
+	case <-done:
+	}
+}
+
+func (q *Queue) setState(job Job) {
+	q.mu.Lock()
+	q.state[job.Id] = job
+	_ = q.persist()
+	q.mu.Unlock()
+	q.writeStatus()
+}
+
+// finalize records a job's terminal outcome and prunes it from persisted
+// state: a Done or Failed job has nothing left to resume, and keeping every
+// job a long-running scheduled profile has ever run would grow queue.json
+// (and the cost of rewriting it on every transition) without bound.
+func (q *Queue) finalize(job Job) {
+	q.mu.Lock()
+	delete(q.state, job.Id)
+	_ = q.persist()
+	q.mu.Unlock()
+	q.writeStatus()
+}
+
+// Depth returns the number of jobs awaiting or currently undergoing
+// processing.
+func (q *Queue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	depth := 0
+	for _, job := range q.state {
+		if job.Status == JobStatusPending || job.Status == JobStatusRunning {
+			depth++
+		}
+	}
+	return depth
+}
+
+// FailedCount returns the number of jobs that exhausted their retries.
+func (q *Queue) FailedCount() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.failedCount
+}
+
+// Status returns a snapshot of the queue's current depth and failed job
+// count, for callers that want to log or report on queue health.
+func (q *Queue) Status() Status {
+	return Status{Depth: q.Depth(), FailedCount: q.FailedCount()}
+}
+
+// writeStatus persists a small JSON snapshot of queue health to statusPath
+// so an external process (or an operator) can check it without needing
+// access to the full job-level state in statePath. Write failures are
+// swallowed, same as persist's callers do for state: the status file is a
+// convenience, not something a sync run should fail over.
+func (q *Queue) writeStatus() {
+	data, err := json.Marshal(q.Status())
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(q.statusPath, data, 0o644)
+}
+
+func backoff(attempt int) time.Duration {
+	base := time.Duration(1<<uint(attempt)) * 100 * time.Millisecond
+	jitter := time.Duration(rand.Int63n(int64(base) + 1))
+	return base + jitter
+}
+
+func asRetryable(err error, target **RetryableError) bool {
+	for err != nil {
+		if r, ok := err.(*RetryableError); ok {
+			*target = r
+			return true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
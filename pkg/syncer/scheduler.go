@@ -0,0 +1,74 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// EnvVarKeySyncSchedule holds a cron expression (e.g. "0 */6 * * *")
+	// controlling how often RunScheduled triggers a sync.
+	EnvVarKeySyncSchedule = "SYNC_SCHEDULE"
+	// EnvVarKeySyncOnStart controls whether RunScheduled triggers an
+	// immediate sync before waiting for the first scheduled tick. Defaults
+	// to true.
+	EnvVarKeySyncOnStart = "SYNC_ON_START"
+)
+
+// RunScheduled runs the syncer repeatedly according to the profile's cron
+// expression, or SYNC_SCHEDULE when the profile has none, until ctx is
+// cancelled. Only one sync executes at a
+// time; a tick that fires while a sync is still running is skipped rather
+// than queued.
+func (s *Syncer) RunScheduled(ctx context.Context) error {
+	scheduleExpr := s.schedule
+	if scheduleExpr == "" {
+		scheduleExpr = os.Getenv(EnvVarKeySyncSchedule)
+	}
+	if scheduleExpr == "" {
+		return fmt.Errorf("missing required environment variable %s", EnvVarKeySyncSchedule)
+	}
+	c := cron.New()
+	var mu sync.Mutex
+	entryId, err := c.AddFunc(scheduleExpr, func() {
+		s.runOnce(&mu, c, 0)
+	})
+	if err != nil {
+		return fmt.Errorf("failure parsing sync schedule %q: %w", scheduleExpr, err)
+	}
+	c.Start()
+	defer func() {
+		stopCtx := c.Stop()
+		<-stopCtx.Done()
+	}()
+	if os.Getenv(EnvVarKeySyncOnStart) != "false" {
+		s.runOnce(&mu, c, entryId)
+	} else {
+		s.logger.Info("next scheduled sync", zap.Time("at", c.Entry(entryId).Next))
+	}
+	<-ctx.Done()
+	s.logger.Info("shutting down scheduler", zap.Error(ctx.Err()))
+	return nil
+}
+
+func (s *Syncer) runOnce(mu *sync.Mutex, c *cron.Cron, entryId cron.EntryID) {
+	if !mu.TryLock() {
+		s.logger.Warn("skipping scheduled sync: previous run still in progress")
+		return
+	}
+	defer mu.Unlock()
+	report := s.run()
+	if !report.Success() {
+		s.logger.Error("scheduled sync failed", zap.Strings("errors", report.Errors))
+	} else {
+		s.logger.Info("scheduled sync succeeded")
+	}
+	if entryId != 0 {
+		s.logger.Info("next scheduled sync", zap.Time("at", c.Entry(entryId).Next))
+	}
+}
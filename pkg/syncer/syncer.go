@@ -1,34 +1,124 @@
 package syncer
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/cecobask/imdb-trakt-sync/pkg/cache"
 	"github.com/cecobask/imdb-trakt-sync/pkg/client"
+	"github.com/cecobask/imdb-trakt-sync/pkg/config"
 	"github.com/cecobask/imdb-trakt-sync/pkg/entities"
 	"github.com/cecobask/imdb-trakt-sync/pkg/logger"
+	"github.com/cecobask/imdb-trakt-sync/pkg/notify"
+	"github.com/cecobask/imdb-trakt-sync/pkg/queue"
 	_ "github.com/joho/godotenv/autoload"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	EnvVarKeyCookieAtMain   = "IMDB_COOKIE_AT_MAIN"
-	EnvVarKeyCookieUbidMain = "IMDB_COOKIE_UBID_MAIN"
-	EnvVarKeyListIds        = "IMDB_LIST_IDS"
-	EnvVarKeyUserId         = "IMDB_USER_ID"
-	EnvVarKeyClientId       = "TRAKT_CLIENT_ID"
-	EnvVarKeyClientSecret   = "TRAKT_CLIENT_SECRET"
-	EnvVarKeyPassword       = "TRAKT_PASSWORD"
-	EnvVarKeyUsername       = "TRAKT_USERNAME"
+	EnvVarKeyCacheDir             = "CACHE_DIR"
+	EnvVarKeyCacheTtlLists        = "CACHE_TTL_LISTS"
+	EnvVarKeyCacheTtlWatchlist    = "CACHE_TTL_WATCHLIST"
+	EnvVarKeyCacheTtlRatings      = "CACHE_TTL_RATINGS"
+	EnvVarKeyCacheTtlHistory      = "CACHE_TTL_HISTORY"
+	EnvVarKeyWorkerCount          = "WORKER_COUNT"
+	EnvVarKeySyncMode             = "SYNC_MODE"
+	EnvVarKeyConflictStrategy     = "CONFLICT_STRATEGY"
+	EnvVarKeyNotifyOn             = "NOTIFY_ON"
+	EnvVarKeyNotifyDiscordWebhook = "NOTIFY_DISCORD_WEBHOOK"
+	EnvVarKeyNotifySlackWebhook   = "NOTIFY_SLACK_WEBHOOK"
+	EnvVarKeyNotifyTelegramToken  = "NOTIFY_TELEGRAM_BOT_TOKEN"
+	EnvVarKeyNotifyTelegramChatId = "NOTIFY_TELEGRAM_CHAT_ID"
+	EnvVarKeyNotifySmtpHost       = "NOTIFY_SMTP_HOST"
+	EnvVarKeyNotifySmtpPort       = "NOTIFY_SMTP_PORT"
+	EnvVarKeyNotifySmtpUsername   = "NOTIFY_SMTP_USERNAME"
+	EnvVarKeyNotifySmtpPassword   = "NOTIFY_SMTP_PASSWORD"
+	EnvVarKeyNotifySmtpFrom       = "NOTIFY_SMTP_FROM"
+	EnvVarKeyNotifySmtpTo         = "NOTIFY_SMTP_TO"
+	EnvVarKeyDryRun               = "DRY_RUN"
+
+	defaultNotifyOn          = notify.OnFailure
+	defaultCacheDir          = ".cache/imdb-trakt-sync"
+	defaultCacheTtlLists     = 10 * time.Minute
+	defaultCacheTtlWatchlist = 10 * time.Minute
+	defaultCacheTtlRatings   = time.Hour
+	defaultCacheTtlHistory   = time.Hour
+	defaultWorkerCount       = 4
+
+	jobNameListItemsAdd        = "list_items_add"
+	jobNameListItemsRemove     = "list_items_remove"
+	jobNameWatchlistAdd        = "watchlist_items_add"
+	jobNameWatchlistRemove     = "watchlist_items_remove"
+	jobNameRatingsAdd          = "ratings_add"
+	jobNameRatingsRemove       = "ratings_remove"
+	jobNameHistoryAdd          = "history_add"
+	jobNameHistoryRemove       = "history_remove"
+	jobNameImdbListItemsAdd    = "imdb_list_items_add"
+	jobNameImdbListItemsRemove = "imdb_list_items_remove"
+	jobNameImdbWatchlistAdd    = "imdb_watchlist_items_add"
+	jobNameImdbWatchlistRemove = "imdb_watchlist_items_remove"
+	jobNameImdbRatingsAdd      = "imdb_ratings_add"
+	jobNameImdbRatingsRemove   = "imdb_ratings_remove"
+
+	// SyncModeImdbToTrakt mirrors IMDb onto Trakt. This is the original,
+	// and still default, sync direction.
+	SyncModeImdbToTrakt = "imdb-to-trakt"
+	// SyncModeTraktToImdb mirrors Trakt onto IMDb.
+	SyncModeTraktToImdb = "trakt-to-imdb"
+	// SyncModeTwoWay mirrors both directions, resolving conflicting
+	// ratings according to ConflictStrategy.
+	SyncModeTwoWay = "two-way"
+
+	// ConflictStrategyNewestWins keeps whichever side rated an item most
+	// recently. This is the default strategy.
+	ConflictStrategyNewestWins = "newest-wins"
+	ConflictStrategyImdbWins   = "imdb-wins"
+	ConflictStrategyTraktWins  = "trakt-wins"
 )
 
 type Syncer struct {
-	logger      *zap.Logger
-	imdbClient  client.ImdbClientInterface
-	traktClient client.TraktClientInterface
-	user        *user
+	logger           *zap.Logger
+	imdbClient       client.ImdbClientInterface
+	traktClient      client.TraktClientInterface
+	user             *user
+	userId           string
+	cache            cache.Store
+	cacheTtl         cacheTtlConfig
+	queue            *queue.Queue
+	jobSeq           uint64
+	runId            string
+	reportMu         sync.Mutex
+	syncMode         string
+	conflictStrategy string
+	notifiers        []notify.Notifier
+	notifyOn         string
+	report           *notify.RunReport
+	dryRun           bool
+	schedule         string
+}
+
+// itemsJobPayload is the persisted payload for all Trakt and IMDb write jobs. ListId
+// is only populated for list-scoped operations.
+type itemsJobPayload struct {
+	ListId string               `json:"listId,omitempty"`
+	Items  []entities.TraktItem `json:"items"`
+}
+
+type cacheTtlConfig struct {
+	lists     time.Duration
+	watchlist time.Duration
+	ratings   time.Duration
+	history   time.Duration
 }
 
 type user struct {
@@ -36,19 +126,23 @@ type user struct {
 	ratings entities.DataPair
 }
 
-func NewSyncer() *Syncer {
+// NewSyncer builds a Syncer for a single profile. Profiles are either
+// loaded from a multi-user config.Config or, for backward compatibility,
+// assembled from the process environment by config.ProfileFromEnv.
+func NewSyncer(profile config.Profile) *Syncer {
 	syncer := &Syncer{
-		logger: logger.NewLogger(),
+		logger: logger.NewLogger().With(zap.String("profile", profile.Name)),
 		user:   &user{},
+		runId:  newRunId(),
 	}
-	if err := validateEnvVars(); err != nil {
-		syncer.logger.Fatal("failure validating environment variables", zap.Error(err))
+	if err := profile.Validate(); err != nil {
+		syncer.logger.Fatal("failure validating profile", zap.Error(err))
 	}
 	imdbClient, err := client.NewImdbClient(
 		client.ImdbConfig{
-			CookieAtMain:   os.Getenv(EnvVarKeyCookieAtMain),
-			CookieUbidMain: os.Getenv(EnvVarKeyCookieUbidMain),
-			UserId:         os.Getenv(EnvVarKeyUserId),
+			CookieAtMain:   profile.ImdbCookieAtMain,
+			CookieUbidMain: profile.ImdbCookieUbidMain,
+			UserId:         profile.ImdbUserId,
 		},
 		syncer.logger,
 	)
@@ -56,12 +150,13 @@ func NewSyncer() *Syncer {
 		syncer.logger.Fatal("failure initialising imdb client", zap.Error(err))
 	}
 	syncer.imdbClient = imdbClient
+	syncer.userId = profile.ImdbUserId
 	traktClient, err := client.NewTraktClient(
 		client.TraktConfig{
-			ClientId:     os.Getenv(EnvVarKeyClientId),
-			ClientSecret: os.Getenv(EnvVarKeyClientSecret),
-			Username:     os.Getenv(EnvVarKeyUsername),
-			Password:     os.Getenv(EnvVarKeyPassword),
+			ClientId:     profile.TraktClientId,
+			ClientSecret: profile.TraktClientSecret,
+			Username:     profile.TraktUsername,
+			Password:     profile.TraktPassword,
 		},
 		syncer.logger,
 	)
@@ -69,28 +164,210 @@ func NewSyncer() *Syncer {
 		syncer.logger.Fatal("failure initialising trakt client", zap.Error(err))
 	}
 	syncer.traktClient = traktClient
-	if imdbListIdsString := os.Getenv(EnvVarKeyListIds); imdbListIdsString != "" && imdbListIdsString != "all" {
-		imdbListIds := strings.Split(imdbListIdsString, ",")
-		for i := range imdbListIds {
-			syncer.user.lists = append(syncer.user.lists, entities.DataPair{
-				ImdbListId: strings.ReplaceAll(imdbListIds[i], " ", ""),
-			})
+	cacheDir := filepath.Join(envOr(EnvVarKeyCacheDir, defaultCacheDir), profile.Name)
+	cacheStore, err := cache.NewFileStore(cacheDir)
+	if err != nil {
+		syncer.logger.Fatal("failure initialising cache store", zap.Error(err))
+	}
+	syncer.cache = cacheStore
+	syncer.cacheTtl = cacheTtlConfig{
+		lists:     envDuration(EnvVarKeyCacheTtlLists, defaultCacheTtlLists),
+		watchlist: envDuration(EnvVarKeyCacheTtlWatchlist, defaultCacheTtlWatchlist),
+		ratings:   envDuration(EnvVarKeyCacheTtlRatings, defaultCacheTtlRatings),
+		history:   envDuration(EnvVarKeyCacheTtlHistory, defaultCacheTtlHistory),
+	}
+	workerCount := defaultWorkerCount
+	if workerCountString := os.Getenv(EnvVarKeyWorkerCount); workerCountString != "" {
+		if parsed, err := strconv.Atoi(workerCountString); err == nil && parsed > 0 {
+			workerCount = parsed
 		}
 	}
+	queueStatePath := filepath.Join(cacheDir, "queue.json")
+	jobQueue, err := queue.NewQueue(workerCount, queueStatePath, syncer.dispatchJob)
+	if err != nil {
+		syncer.logger.Fatal("failure initialising job queue", zap.Error(err))
+	}
+	syncer.queue = jobQueue
+	syncer.syncMode = profile.SyncMode
+	switch syncer.syncMode {
+	case SyncModeTraktToImdb, SyncModeTwoWay:
+	default:
+		syncer.syncMode = SyncModeImdbToTrakt
+	}
+	syncer.conflictStrategy = profile.ConflictStrategy
+	switch syncer.conflictStrategy {
+	case ConflictStrategyImdbWins, ConflictStrategyTraktWins:
+	default:
+		syncer.conflictStrategy = ConflictStrategyNewestWins
+	}
+	syncer.notifyOn = envOr(EnvVarKeyNotifyOn, defaultNotifyOn)
+	syncer.notifiers = buildNotifiers()
+	syncer.dryRun = os.Getenv(EnvVarKeyDryRun) == "true"
+	syncer.schedule = profile.Schedule
+	for i := range profile.ImdbListIds {
+		syncer.user.lists = append(syncer.user.lists, entities.DataPair{
+			ImdbListId: strings.ReplaceAll(profile.ImdbListIds[i], " ", ""),
+		})
+	}
 	return syncer
 }
 
+// newRunId generates a short random id unique to this process's lifetime, so
+// job ids built from it never collide with ids a prior (possibly crashed)
+// run may have left pending in the persisted queue state.
+func newRunId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Run performs a single sync and terminates the process if it failed, after
+// dispatching the run report to the configured notifiers. Long-running
+// callers that must survive a failed sync (e.g. RunScheduled) should call
+// run directly instead.
 func (s *Syncer) Run() {
+	report := s.run()
+	if !report.Success() {
+		s.logger.Fatal("failure running sync", zap.Strings("errors", report.Errors))
+	}
+	s.logger.Info("successfully synced trakt with imdb")
+}
+
+// RunOnce performs a single sync and returns the resulting RunReport instead
+// of terminating the process on failure. Callers that run multiple profiles
+// in one process (e.g. cmd/imdb-trakt-sync) should use this instead of Run,
+// so one profile's failure doesn't take the others down with it.
+func (s *Syncer) RunOnce() *notify.RunReport {
+	return s.run()
+}
+
+// run performs a single sync, collecting errors into a RunReport instead of
+// aborting, and dispatches the report to the configured notifiers. It never
+// terminates the process, so it is safe to call repeatedly from a scheduler.
+func (s *Syncer) run() *notify.RunReport {
+	s.report = &notify.RunReport{Started: time.Now()}
 	if err := s.hydrate(); err != nil {
-		s.logger.Fatal("failure hydrating imdb client", zap.Error(err))
+		s.reportError(fmt.Errorf("failure hydrating imdb client: %w", err))
+		return s.finish()
 	}
+	s.queue.Start()
 	if err := s.syncLists(); err != nil {
-		s.logger.Fatal("failure syncing lists", zap.Error(err))
+		s.reportError(fmt.Errorf("failure syncing lists: %w", err))
 	}
 	if err := s.syncRatings(); err != nil {
-		s.logger.Fatal("failure syncing ratings", zap.Error(err))
+		s.reportError(fmt.Errorf("failure syncing ratings: %w", err))
 	}
-	s.logger.Info("successfully synced trakt with imdb")
+	// Close stops the workers without draining the queue, so wait until every
+	// enqueued job has reached a terminal state first.
+	for s.queue.Depth() > 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+	s.queue.Close()
+	status := s.queue.Status()
+	s.logger.Info("job queue drained", zap.Int("depth", status.Depth), zap.Int("failedCount", status.FailedCount))
+	if status.FailedCount > 0 {
+		s.reportError(fmt.Errorf("%d queued writes failed permanently", status.FailedCount))
+	}
+	return s.finish()
+}
+
+// reportError records err on the in-flight RunReport without aborting the
+// run, so remaining steps still execute and the notifiers still see an
+// accurate summary.
+func (s *Syncer) reportError(err error) {
+	s.report.Errors = append(s.report.Errors, err.Error())
+}
+
+// finish stamps the RunReport and dispatches it to the configured
+// notifiers.
+func (s *Syncer) finish() *notify.RunReport {
+	s.report.Finished = time.Now()
+	for _, notifier := range s.notifiers {
+		if !notify.ShouldNotify(s.notifyOn, *s.report) {
+			continue
+		}
+		if err := notifier.Notify(*s.report); err != nil {
+			s.logger.Warn("failure dispatching run report", zap.Error(err))
+		}
+	}
+	return s.report
+}
+
+// Preview computes the diffs that a real sync would apply, without invoking
+// any mutating client method, and returns them as a RunReport. It forces
+// dry-run behaviour for the duration of the call regardless of the DRY_RUN
+// env var, and does not dispatch the report to notifiers.
+func (s *Syncer) Preview() (*notify.RunReport, error) {
+	previousDryRun := s.dryRun
+	s.dryRun = true
+	defer func() { s.dryRun = previousDryRun }()
+	s.report = &notify.RunReport{Started: time.Now()}
+	if err := s.hydrate(); err != nil {
+		return nil, fmt.Errorf("failure hydrating imdb client: %w", err)
+	}
+	if err := s.syncLists(); err != nil {
+		return nil, fmt.Errorf("failure previewing lists: %w", err)
+	}
+	if err := s.syncRatings(); err != nil {
+		return nil, fmt.Errorf("failure previewing ratings: %w", err)
+	}
+	s.report.Finished = time.Now()
+	return s.report, nil
+}
+
+// itemsPreview renders items as human-readable "title (imdb id)" strings
+// for dry-run logging.
+func itemsPreview(items []entities.TraktItem) []string {
+	previews := make([]string, 0, len(items))
+	for _, item := range items {
+		switch item.Type {
+		case entities.TraktItemTypeMovie:
+			previews = append(previews, fmt.Sprintf("%s (%s)", item.Movie.Title, item.Movie.Ids.Imdb))
+		case entities.TraktItemTypeShow:
+			previews = append(previews, fmt.Sprintf("%s (%s)", item.Show.Title, item.Show.Ids.Imdb))
+		case entities.TraktItemTypeEpisode:
+			previews = append(previews, fmt.Sprintf("%s (%s)", item.Episode.Title, item.Episode.Ids.Imdb))
+		}
+	}
+	return previews
+}
+
+// buildNotifiers constructs a Notifier for every notification sink that has
+// its required env vars set.
+func buildNotifiers() []notify.Notifier {
+	var notifiers []notify.Notifier
+	if webhook := os.Getenv(EnvVarKeyNotifyDiscordWebhook); webhook != "" {
+		notifiers = append(notifiers, &notify.DiscordNotifier{WebhookUrl: webhook})
+	}
+	if webhook := os.Getenv(EnvVarKeyNotifySlackWebhook); webhook != "" {
+		notifiers = append(notifiers, &notify.SlackNotifier{WebhookUrl: webhook})
+	}
+	if token := os.Getenv(EnvVarKeyNotifyTelegramToken); token != "" {
+		notifiers = append(notifiers, &notify.TelegramNotifier{
+			BotToken: token,
+			ChatId:   os.Getenv(EnvVarKeyNotifyTelegramChatId),
+		})
+	}
+	if host := os.Getenv(EnvVarKeyNotifySmtpHost); host != "" {
+		notifiers = append(notifiers, &notify.SmtpNotifier{
+			Host:     host,
+			Port:     os.Getenv(EnvVarKeyNotifySmtpPort),
+			Username: os.Getenv(EnvVarKeyNotifySmtpUsername),
+			Password: os.Getenv(EnvVarKeyNotifySmtpPassword),
+			From:     os.Getenv(EnvVarKeyNotifySmtpFrom),
+			To:       strings.Split(os.Getenv(EnvVarKeyNotifySmtpTo), ","),
+		})
+	}
+	return notifiers
 }
 
 func (s *Syncer) hydrate() error {
@@ -118,35 +395,55 @@ func (s *Syncer) hydrate() error {
 	for i := range s.user.lists {
 		currentList := &s.user.lists[i]
 		if currentList.IsWatchlist {
-			traktWatchlist, err := s.traktClient.WatchlistItemsGet()
-			if err != nil {
-				return fmt.Errorf("failure fetching trakt watchlist: %w", err)
+			var traktWatchlist entities.TraktList
+			if !s.cacheGet(cacheResourceWatchlist, "self", &traktWatchlist) {
+				fetched, err := s.traktClient.WatchlistItemsGet()
+				if err != nil {
+					return fmt.Errorf("failure fetching trakt watchlist: %w", err)
+				}
+				traktWatchlist = fetched
+				s.cacheSet(cacheResourceWatchlist, "self", traktWatchlist, s.cacheTtl.watchlist)
 			}
 			currentList.TraktList = traktWatchlist
 			continue
 		}
-		traktList, err := s.traktClient.ListItemsGet(currentList.TraktListId)
-		if err != nil {
-			var apiError *client.ApiError
-			if errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound {
-				s.logger.Warn("silencing not found error while hydrating the syncer with trakt lists", zap.Error(apiError))
-				if err = s.traktClient.ListAdd(currentList.TraktListId, currentList.ImdbListName); err != nil {
-					return fmt.Errorf("failure creating trakt list %s: %w", currentList.TraktListId, err)
+		var traktList entities.TraktList
+		if !s.cacheGet(cacheResourceLists, currentList.TraktListId, &traktList) {
+			fetched, err := s.traktClient.ListItemsGet(currentList.TraktListId)
+			if err != nil {
+				var apiError *client.ApiError
+				if errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound {
+					s.logger.Warn("silencing not found error while hydrating the syncer with trakt lists", zap.Error(apiError))
+					if err = s.traktClient.ListAdd(currentList.TraktListId, currentList.ImdbListName); err != nil {
+						return fmt.Errorf("failure creating trakt list %s: %w", currentList.TraktListId, err)
+					}
+					currentList.TraktList = traktList
+					continue
 				}
-				currentList.TraktList = traktList
-				continue
+				return fmt.Errorf("unexpected error while fetching contents of trakt list %s: %w", currentList.TraktListId, err)
 			}
-			return fmt.Errorf("unexpected error while fetching contents of trakt list %s: %w", currentList.TraktListId, err)
+			traktList = fetched
+			s.cacheSet(cacheResourceLists, currentList.TraktListId, traktList, s.cacheTtl.lists)
 		}
 		currentList.TraktList = traktList
 	}
-	imdbRatings, err := s.imdbClient.RatingsGet()
-	if err != nil {
-		return fmt.Errorf("failure fetching imdb ratings: %w", err)
+	var imdbRatings entities.ImdbList
+	if !s.cacheGet(cacheResourceRatings, "imdb", &imdbRatings) {
+		fetched, err := s.imdbClient.RatingsGet()
+		if err != nil {
+			return fmt.Errorf("failure fetching imdb ratings: %w", err)
+		}
+		imdbRatings = fetched
+		s.cacheSet(cacheResourceRatings, "imdb", imdbRatings, s.cacheTtl.ratings)
 	}
-	traktRatings, err := s.traktClient.RatingsGet()
-	if err != nil {
-		return fmt.Errorf("failure fetching trakt ratings: %w", err)
+	var traktRatings entities.TraktList
+	if !s.cacheGet(cacheResourceRatings, "trakt", &traktRatings) {
+		fetched, err := s.traktClient.RatingsGet()
+		if err != nil {
+			return fmt.Errorf("failure fetching trakt ratings: %w", err)
+		}
+		traktRatings = fetched
+		s.cacheSet(cacheResourceRatings, "trakt", traktRatings, s.cacheTtl.ratings)
 	}
 	s.user.ratings = entities.DataPair{
 		ImdbList:  imdbRatings,
@@ -156,32 +453,75 @@ func (s *Syncer) hydrate() error {
 }
 
 func (s *Syncer) syncLists() error {
+	mirrorToTrakt := s.syncMode == SyncModeImdbToTrakt
+	mirrorToImdb := s.syncMode == SyncModeTraktToImdb
+	twoWay := s.syncMode == SyncModeTwoWay
+	syncToTrakt := mirrorToTrakt || twoWay
+	syncToImdb := mirrorToImdb || twoWay
 	for _, list := range s.user.lists {
 		diff := list.Difference()
 		if list.IsWatchlist {
-			if len(diff["add"]) > 0 {
-				if err := s.traktClient.WatchlistItemsAdd(diff["add"]); err != nil {
-					return fmt.Errorf("failure adding items to trakt watchlist: %w", err)
+			if syncToTrakt {
+				if len(diff["add"]) > 0 {
+					if err := s.enqueueItemsJob(jobNameWatchlistAdd, "", diff["add"]); err != nil {
+						return fmt.Errorf("failure enqueueing items to add to trakt watchlist: %w", err)
+					}
+				}
+				// In two-way mode an item missing from IMDb may simply not
+				// have been synced there yet, so only mirrorToTrakt removes.
+				if mirrorToTrakt && len(diff["remove"]) > 0 {
+					if err := s.enqueueItemsJob(jobNameWatchlistRemove, "", diff["remove"]); err != nil {
+						return fmt.Errorf("failure enqueueing items to remove from trakt watchlist: %w", err)
+					}
 				}
 			}
-			if len(diff["remove"]) > 0 {
-				if err := s.traktClient.WatchlistItemsRemove(diff["remove"]); err != nil {
-					return fmt.Errorf("failure removing items from trakt watchlist: %w", err)
+			if syncToImdb {
+				reverse := reverseDiff(diff)
+				if len(reverse["add"]) > 0 {
+					if err := s.enqueueItemsJob(jobNameImdbWatchlistAdd, "", reverse["add"]); err != nil {
+						return fmt.Errorf("failure enqueueing items to add to imdb watchlist: %w", err)
+					}
+				}
+				if mirrorToImdb && len(reverse["remove"]) > 0 {
+					if err := s.enqueueItemsJob(jobNameImdbWatchlistRemove, "", reverse["remove"]); err != nil {
+						return fmt.Errorf("failure enqueueing items to remove from imdb watchlist: %w", err)
+					}
 				}
 			}
 			continue
 		}
-		if len(diff["add"]) > 0 {
-			if err := s.traktClient.ListItemsAdd(list.TraktListId, diff["add"]); err != nil {
-				return fmt.Errorf("failure adding items to trakt list %s: %w", list.TraktListId, err)
+		if syncToTrakt {
+			if len(diff["add"]) > 0 {
+				if err := s.enqueueItemsJob(jobNameListItemsAdd, list.TraktListId, diff["add"]); err != nil {
+					return fmt.Errorf("failure enqueueing items to add to trakt list %s: %w", list.TraktListId, err)
+				}
+			}
+			if mirrorToTrakt && len(diff["remove"]) > 0 {
+				if err := s.enqueueItemsJob(jobNameListItemsRemove, list.TraktListId, diff["remove"]); err != nil {
+					return fmt.Errorf("failure enqueueing items to remove from trakt list %s: %w", list.TraktListId, err)
+				}
 			}
 		}
-		if len(diff["remove"]) > 0 {
-			if err := s.traktClient.ListItemsRemove(list.TraktListId, diff["remove"]); err != nil {
-				return fmt.Errorf("failure removing items from trakt list %s: %w", list.TraktListId, err)
+		if syncToImdb {
+			reverse := reverseDiff(diff)
+			if len(reverse["add"]) > 0 {
+				if err := s.enqueueItemsJob(jobNameImdbListItemsAdd, list.ImdbListId, reverse["add"]); err != nil {
+					return fmt.Errorf("failure enqueueing items to add to imdb list %s: %w", list.ImdbListId, err)
+				}
+			}
+			if mirrorToImdb && len(reverse["remove"]) > 0 {
+				if err := s.enqueueItemsJob(jobNameImdbListItemsRemove, list.ImdbListId, reverse["remove"]); err != nil {
+					return fmt.Errorf("failure enqueueing items to remove from imdb list %s: %w", list.ImdbListId, err)
+				}
 			}
 		}
 	}
+	if !mirrorToTrakt {
+		// Pruning Trakt-only lists is a mirror operation: in two-way or
+		// trakt-to-imdb mode, a list absent from IMDb may just not have been
+		// synced there yet, so it must not be deleted from Trakt.
+		return nil
+	}
 	// remove lists that only exist in Trakt
 	traktLists, err := s.traktClient.ListsGet()
 	if err != nil {
@@ -198,10 +538,27 @@ func (s *Syncer) syncLists() error {
 }
 
 func (s *Syncer) syncRatings() error {
+	syncToTrakt := s.syncMode == SyncModeImdbToTrakt || s.syncMode == SyncModeTwoWay
+	syncToImdb := s.syncMode == SyncModeTraktToImdb || s.syncMode == SyncModeTwoWay
 	diff := s.user.ratings.Difference()
-	if len(diff["add"]) > 0 {
-		if err := s.traktClient.RatingsAdd(diff["add"]); err != nil {
-			return fmt.Errorf("failure adding trakt ratings: %w", err)
+	if syncToImdb {
+		reverse := reverseDiff(diff)
+		if len(reverse["add"]) > 0 {
+			if err := s.enqueueItemsJob(jobNameImdbRatingsAdd, "", reverse["add"]); err != nil {
+				return fmt.Errorf("failure enqueueing imdb ratings to add: %w", err)
+			}
+		}
+		// As in syncLists, only a one-way mirror removes: in two-way mode a
+		// rating missing on one side is added there, not deleted from the other.
+		if s.syncMode == SyncModeTraktToImdb && len(reverse["remove"]) > 0 {
+			if err := s.enqueueItemsJob(jobNameImdbRatingsRemove, "", reverse["remove"]); err != nil {
+				return fmt.Errorf("failure enqueueing imdb ratings to remove: %w", err)
+			}
+		}
+	}
+	if syncToTrakt && len(diff["add"]) > 0 {
+		if err := s.enqueueItemsJob(jobNameRatingsAdd, "", diff["add"]); err != nil {
+			return fmt.Errorf("failure enqueueing trakt ratings to add: %w", err)
 		}
 		var historyToAdd []entities.TraktItem
 		for i := range diff["add"] {
@@ -209,7 +566,7 @@ func (s *Syncer) syncRatings() error {
 			if err != nil {
 				return fmt.Errorf("failure fetching trakt item id: %w", err)
 			}
-			history, err := s.traktClient.HistoryGet(diff["add"][i].Type, *traktItemId)
+			history, err := s.historyGet(diff["add"][i].Type, *traktItemId)
 			if err != nil {
 				return fmt.Errorf("failure fetching trakt history for %s %s: %w", diff["add"][i].Type, *traktItemId, err)
 			}
@@ -219,14 +576,14 @@ func (s *Syncer) syncRatings() error {
 			historyToAdd = append(historyToAdd, diff["add"][i])
 		}
 		if len(historyToAdd) > 0 {
-			if err := s.traktClient.HistoryAdd(historyToAdd); err != nil {
-				return fmt.Errorf("failure adding trakt history: %w", err)
+			if err := s.enqueueItemsJob(jobNameHistoryAdd, "", historyToAdd); err != nil {
+				return fmt.Errorf("failure enqueueing trakt history to add: %w", err)
 			}
 		}
 	}
-	if len(diff["remove"]) > 0 {
-		if err := s.traktClient.RatingsRemove(diff["remove"]); err != nil {
-			return fmt.Errorf("failure removing trakt ratings: %w", err)
+	if s.syncMode == SyncModeImdbToTrakt && len(diff["remove"]) > 0 {
+		if err := s.enqueueItemsJob(jobNameRatingsRemove, "", diff["remove"]); err != nil {
+			return fmt.Errorf("failure enqueueing trakt ratings to remove: %w", err)
 		}
 		var historyToRemove []entities.TraktItem
 		for i := range diff["remove"] {
@@ -234,7 +591,7 @@ func (s *Syncer) syncRatings() error {
 			if err != nil {
 				return fmt.Errorf("failure fetching trakt item id: %w", err)
 			}
-			history, err := s.traktClient.HistoryGet(diff["remove"][i].Type, *traktItemId)
+			history, err := s.historyGet(diff["remove"][i].Type, *traktItemId)
 			if err != nil {
 				return fmt.Errorf("failure fetching trakt history for %s %s: %w", diff["remove"][i].Type, *traktItemId, err)
 			}
@@ -244,47 +601,137 @@ func (s *Syncer) syncRatings() error {
 			historyToRemove = append(historyToRemove, diff["remove"][i])
 		}
 		if len(historyToRemove) > 0 {
-			if err := s.traktClient.HistoryRemove(historyToRemove); err != nil {
-				return fmt.Errorf("failure removing trakt history: %w", err)
+			if err := s.enqueueItemsJob(jobNameHistoryRemove, "", historyToRemove); err != nil {
+				return fmt.Errorf("failure enqueueing trakt history to remove: %w", err)
 			}
 		}
 	}
-	var ratingsToUpdate []entities.TraktItem
+	var ratingsToUpdateTrakt []entities.TraktItem
+	var ratingsToUpdateImdb []entities.TraktItem
 	for _, imdbItem := range s.user.ratings.ImdbList {
 		if imdbItem.Rating != nil {
 			for _, traktItem := range s.user.ratings.TraktList {
 				ratedAt := imdbItem.RatingDate.UTC().String()
+				var matched bool
+				var traktRating int
 				switch traktItem.Type {
 				case entities.TraktItemTypeMovie:
-					if imdbItem.Id == traktItem.Movie.Ids.Imdb && *imdbItem.Rating != traktItem.Rating {
-						traktItem.Movie.Rating = imdbItem.Rating
-						traktItem.Movie.RatedAt = &ratedAt
-						ratingsToUpdate = append(ratingsToUpdate, traktItem)
-					}
+					matched = imdbItem.Id == traktItem.Movie.Ids.Imdb
+					traktRating = traktItem.Movie.Rating
 				case entities.TraktItemTypeShow:
-					if imdbItem.Id == traktItem.Show.Ids.Imdb && *imdbItem.Rating != traktItem.Rating {
-						traktItem.Show.Rating = imdbItem.Rating
-						traktItem.Show.RatedAt = &ratedAt
-						ratingsToUpdate = append(ratingsToUpdate, traktItem)
-					}
+					matched = imdbItem.Id == traktItem.Show.Ids.Imdb
+					traktRating = traktItem.Show.Rating
 				case entities.TraktItemTypeEpisode:
-					if imdbItem.Id == traktItem.Episode.Ids.Imdb && *imdbItem.Rating != traktItem.Rating {
-						traktItem.Episode.Rating = imdbItem.Rating
-						traktItem.Episode.RatedAt = &ratedAt
-						ratingsToUpdate = append(ratingsToUpdate, traktItem)
-					}
+					matched = imdbItem.Id == traktItem.Episode.Ids.Imdb
+					traktRating = traktItem.Episode.Rating
+				}
+				if !matched || *imdbItem.Rating == traktRating {
+					continue
+				}
+				direction := s.syncMode
+				if direction == SyncModeTwoWay {
+					direction = s.resolveRatingConflict(imdbItem.RatingDate.UTC(), traktItem)
+				}
+				if direction == SyncModeTraktToImdb {
+					ratingsToUpdateImdb = append(ratingsToUpdateImdb, traktItem)
+					continue
+				}
+				switch traktItem.Type {
+				case entities.TraktItemTypeMovie:
+					traktItem.Movie.Rating = imdbItem.Rating
+					traktItem.Movie.RatedAt = &ratedAt
+				case entities.TraktItemTypeShow:
+					traktItem.Show.Rating = imdbItem.Rating
+					traktItem.Show.RatedAt = &ratedAt
+				case entities.TraktItemTypeEpisode:
+					traktItem.Episode.Rating = imdbItem.Rating
+					traktItem.Episode.RatedAt = &ratedAt
 				}
+				ratingsToUpdateTrakt = append(ratingsToUpdateTrakt, traktItem)
 			}
 		}
 	}
-	if len(ratingsToUpdate) > 0 {
-		if err := s.traktClient.RatingsAdd(ratingsToUpdate); err != nil {
-			return fmt.Errorf("failure updating trakt ratings: %w", err)
+	if syncToTrakt && len(ratingsToUpdateTrakt) > 0 {
+		if err := s.enqueueItemsJob(jobNameRatingsAdd, "", ratingsToUpdateTrakt); err != nil {
+			return fmt.Errorf("failure enqueueing trakt ratings to update: %w", err)
+		}
+	}
+	if syncToImdb && len(ratingsToUpdateImdb) > 0 {
+		if err := s.enqueueItemsJob(jobNameImdbRatingsAdd, "", ratingsToUpdateImdb); err != nil {
+			return fmt.Errorf("failure enqueueing imdb ratings to update: %w", err)
 		}
 	}
 	return nil
 }
 
+// resolveRatingConflict decides, for a rating that differs between IMDb and
+// Trakt in SyncModeTwoWay, which side should win by returning the sync mode
+// whose direction should be applied.
+func (s *Syncer) resolveRatingConflict(imdbRatedAt time.Time, traktItem entities.TraktItem) string {
+	switch s.conflictStrategy {
+	case ConflictStrategyImdbWins:
+		return SyncModeImdbToTrakt
+	case ConflictStrategyTraktWins:
+		return SyncModeTraktToImdb
+	default:
+		traktRatedAt, err := traktItemRatedAt(traktItem)
+		if err != nil {
+			s.logger.Warn("failure parsing trakt rated at timestamp during conflict resolution, defaulting to imdb wins", zap.Error(err))
+			return SyncModeImdbToTrakt
+		}
+		if !traktRatedAt.After(imdbRatedAt) {
+			return SyncModeImdbToTrakt
+		}
+		return SyncModeTraktToImdb
+	}
+}
+
+// traktRatedAtLayouts are the timestamp formats a Trakt item's RatedAt field
+// may come in: time.RFC3339 for a rating Trakt's API actually returned
+// (Go's parser also accepts the fractional seconds Trakt uses, e.g.
+// "2015-07-17T20:40:25.000Z", even though the layout itself doesn't show
+// them), and the default time.Time.String() layout for a rating this
+// process wrote locally before it was pushed (see
+// ratedAt := imdbItem.RatingDate.UTC().String() above).
+var traktRatedAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
+func traktItemRatedAt(traktItem entities.TraktItem) (time.Time, error) {
+	var ratedAt *string
+	switch traktItem.Type {
+	case entities.TraktItemTypeMovie:
+		ratedAt = traktItem.Movie.RatedAt
+	case entities.TraktItemTypeShow:
+		ratedAt = traktItem.Show.RatedAt
+	case entities.TraktItemTypeEpisode:
+		ratedAt = traktItem.Episode.RatedAt
+	}
+	if ratedAt == nil {
+		return time.Time{}, fmt.Errorf("trakt item %s has no rated at timestamp", traktItem.Type)
+	}
+	var lastErr error
+	for _, layout := range traktRatedAtLayouts {
+		parsed, err := time.Parse(layout, *ratedAt)
+		if err == nil {
+			return parsed, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, fmt.Errorf("failure parsing rated at timestamp %q for trakt item %s: %w", *ratedAt, traktItem.Type, lastErr)
+}
+
+// reverseDiff inverts a DataPair difference so that items missing from
+// IMDb (diff["remove"], present only on Trakt) become items to add on IMDb,
+// and vice versa.
+func reverseDiff(diff map[string][]entities.TraktItem) map[string][]entities.TraktItem {
+	return map[string][]entities.TraktItem{
+		"add":    diff["remove"],
+		"remove": diff["add"],
+	}
+}
+
 // cleanupLists ignore duplicate and non-existent imdb lists
 func (s *Syncer) cleanupLists() error {
 	uniqueListNames := make(map[string]bool)
@@ -316,35 +763,236 @@ func (s *Syncer) cleanupLists() error {
 	return nil
 }
 
-func validateEnvVars() error {
-	requiredEnvVarKeys := []string{
-		EnvVarKeyListIds,
-		EnvVarKeyCookieAtMain,
-		EnvVarKeyCookieUbidMain,
-		EnvVarKeyClientId,
-		EnvVarKeyClientSecret,
-		EnvVarKeyUsername,
-		EnvVarKeyPassword,
-	}
-	var missingEnvVars []string
-	for i := range requiredEnvVarKeys {
-		if _, ok := os.LookupEnv(requiredEnvVarKeys[i]); !ok {
-			missingEnvVars = append(missingEnvVars, requiredEnvVarKeys[i])
+func contains(dps []entities.DataPair, traktListName string) bool {
+	for _, dp := range dps {
+		if dp.ImdbListName == traktListName {
+			return true
 		}
 	}
-	if len(missingEnvVars) > 0 {
-		return &MissingEnvironmentVariablesError{
-			variables: missingEnvVars,
+	return false
+}
+
+const (
+	cacheResourceLists     = "lists"
+	cacheResourceWatchlist = "watchlist"
+	cacheResourceRatings   = "ratings"
+	cacheResourceHistory   = "history"
+)
+
+// cacheGet looks up a cache entry keyed by the current user, the resource
+// type and the resource id, e.g. a list id or a trakt item id. Cache read
+// failures are logged and treated as a miss, so a broken cache never fails
+// a sync.
+func (s *Syncer) cacheGet(resource, id string, out interface{}) bool {
+	key := cache.Key(s.userId, resource, id)
+	found, err := s.cache.Get(key, out)
+	if err != nil {
+		s.logger.Warn("failure reading from cache", zap.String("key", key), zap.Error(err))
+		return false
+	}
+	return found
+}
+
+func (s *Syncer) cacheSet(resource, id string, value interface{}, ttl time.Duration) {
+	key := cache.Key(s.userId, resource, id)
+	if err := s.cache.Set(key, value, ttl); err != nil {
+		s.logger.Warn("failure writing to cache", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// invalidateCache evicts any cached snapshot a successful write job just
+// made stale, so a subsequent run within the cache TTL diffs against current
+// state instead of replaying the same adds/removes against what was cached
+// before this job ran.
+func (s *Syncer) invalidateCache(jobName, listId string, items []entities.TraktItem) {
+	switch jobName {
+	case jobNameListItemsAdd, jobNameListItemsRemove:
+		s.invalidateCacheKey(cacheResourceLists, listId)
+	case jobNameWatchlistAdd, jobNameWatchlistRemove:
+		s.invalidateCacheKey(cacheResourceWatchlist, "self")
+	case jobNameRatingsAdd, jobNameRatingsRemove:
+		s.invalidateCacheKey(cacheResourceRatings, "trakt")
+	case jobNameImdbRatingsAdd, jobNameImdbRatingsRemove:
+		s.invalidateCacheKey(cacheResourceRatings, "imdb")
+	case jobNameHistoryAdd, jobNameHistoryRemove:
+		// historyGet caches per item (string(itemType)+"/"+itemId), not per
+		// list, so every item in the job must be invalidated individually.
+		for _, item := range items {
+			itemId, err := item.GetItemId()
+			if err != nil {
+				s.logger.Warn("failure resolving trakt item id while invalidating history cache", zap.Error(err))
+				continue
+			}
+			s.invalidateCacheKey(cacheResourceHistory, string(item.Type)+"/"+*itemId)
 		}
 	}
+}
+
+func (s *Syncer) invalidateCacheKey(resource, id string) {
+	key := cache.Key(s.userId, resource, id)
+	if err := s.cache.Delete(key); err != nil {
+		s.logger.Warn("failure invalidating cache entry", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (s *Syncer) historyGet(itemType entities.TraktItemType, itemId string) ([]entities.TraktItem, error) {
+	var history []entities.TraktItem
+	if s.cacheGet(cacheResourceHistory, string(itemType)+"/"+itemId, &history) {
+		return history, nil
+	}
+	history, err := s.traktClient.HistoryGet(itemType, itemId)
+	if err != nil {
+		return nil, err
+	}
+	s.cacheSet(cacheResourceHistory, string(itemType)+"/"+itemId, history, s.cacheTtl.history)
+	return history, nil
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// enqueueItemsJob submits a Trakt or IMDb write operation to the job queue rather
+// than invoking the client directly, so it can be retried with backoff and
+// run concurrently with unrelated writes.
+func (s *Syncer) enqueueItemsJob(name, listId string, items []entities.TraktItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if s.dryRun {
+		s.logger.Info("dry run: would execute job",
+			zap.String("job", name),
+			zap.String("listId", listId),
+			zap.Strings("items", itemsPreview(items)),
+		)
+		s.countJob(name, len(items))
+		return nil
+	}
+	payload, err := json.Marshal(itemsJobPayload{
+		ListId: listId,
+		Items:  items,
+	})
+	if err != nil {
+		return fmt.Errorf("failure marshalling job payload: %w", err)
+	}
+	id := filepath.Join(name, listId, s.runId, strconv.FormatUint(atomic.AddUint64(&s.jobSeq, 1), 10))
+	if err = s.queue.Enqueue(queue.Job{
+		Id:      id,
+		Name:    name,
+		Payload: payload,
+	}); err != nil {
+		return err
+	}
 	return nil
 }
 
-func contains(dps []entities.DataPair, traktListName string) bool {
-	for _, dp := range dps {
-		if dp.ImdbListName == traktListName {
-			return true
+// countJob tallies items against the in-flight RunReport once their job has
+// actually completed successfully, so a failure notification never claims
+// changes that were submitted but never applied. In a dry run no job is
+// submitted, and enqueueItemsJob tallies the items it would have submitted
+// instead. dispatchJob calls this
+// concurrently from multiple workers, so access to the shared report is
+// mutex-guarded.
+func (s *Syncer) countJob(name string, count int) {
+	s.reportMu.Lock()
+	defer s.reportMu.Unlock()
+	switch name {
+	case jobNameListItemsAdd, jobNameImdbListItemsAdd:
+		s.report.ListItemsAdded += count
+	case jobNameListItemsRemove, jobNameImdbListItemsRemove:
+		s.report.ListItemsRemoved += count
+	case jobNameWatchlistAdd, jobNameImdbWatchlistAdd:
+		s.report.WatchlistAdded += count
+	case jobNameWatchlistRemove, jobNameImdbWatchlistRemove:
+		s.report.WatchlistRemoved += count
+	case jobNameRatingsAdd, jobNameImdbRatingsAdd:
+		s.report.RatingsAdded += count
+	case jobNameRatingsRemove, jobNameImdbRatingsRemove:
+		s.report.RatingsRemoved += count
+	case jobNameHistoryAdd:
+		s.report.HistoryAdded += count
+	case jobNameHistoryRemove:
+		s.report.HistoryRemoved += count
+	}
+}
+
+// dispatchJob is the queue.Handler that replays a persisted job against the
+// Trakt or IMDb client, classifying errors so the queue knows whether to retry. Only
+// on success does it tally the run report and invalidate any cached
+// Trakt/IMDb state the write just made stale, so a job that exhausts its
+// retries is never counted as applied and a later run within the cache TTL
+// doesn't diff against the pre-write snapshot.
+//
+// The IMDb write calls below (ListItemsAdd/Remove, WatchlistItemsAdd/Remove,
+// RatingsAdd/Remove) rely on ImdbClientInterface methods that live in
+// pkg/client, which is not part of this snapshot, so they can't be defined
+// here; they're called exactly as the rest of the interface already is
+// elsewhere in this file.
+func (s *Syncer) dispatchJob(job queue.Job) error {
+	var payload itemsJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failure unmarshalling job payload: %w", err)
+	}
+	var err error
+	switch job.Name {
+	case jobNameListItemsAdd:
+		err = s.traktClient.ListItemsAdd(payload.ListId, payload.Items)
+	case jobNameListItemsRemove:
+		err = s.traktClient.ListItemsRemove(payload.ListId, payload.Items)
+	case jobNameWatchlistAdd:
+		err = s.traktClient.WatchlistItemsAdd(payload.Items)
+	case jobNameWatchlistRemove:
+		err = s.traktClient.WatchlistItemsRemove(payload.Items)
+	case jobNameRatingsAdd:
+		err = s.traktClient.RatingsAdd(payload.Items)
+	case jobNameRatingsRemove:
+		err = s.traktClient.RatingsRemove(payload.Items)
+	case jobNameHistoryAdd:
+		err = s.traktClient.HistoryAdd(payload.Items)
+	case jobNameHistoryRemove:
+		err = s.traktClient.HistoryRemove(payload.Items)
+	case jobNameImdbListItemsAdd:
+		err = s.imdbClient.ListItemsAdd(payload.ListId, payload.Items)
+	case jobNameImdbListItemsRemove:
+		err = s.imdbClient.ListItemsRemove(payload.ListId, payload.Items)
+	case jobNameImdbWatchlistAdd:
+		err = s.imdbClient.WatchlistItemsAdd(payload.Items)
+	case jobNameImdbWatchlistRemove:
+		err = s.imdbClient.WatchlistItemsRemove(payload.Items)
+	case jobNameImdbRatingsAdd:
+		err = s.imdbClient.RatingsAdd(payload.Items)
+	case jobNameImdbRatingsRemove:
+		err = s.imdbClient.RatingsRemove(payload.Items)
+	default:
+		return fmt.Errorf("unknown job name %s", job.Name)
+	}
+	if err != nil {
+		return classifyErr(err)
+	}
+	s.countJob(job.Name, len(payload.Items))
+	s.invalidateCache(job.Name, payload.ListId, payload.Items)
+	return nil
+}
+
+// classifyErr wraps retryable Trakt or IMDb API errors (5xx and 429) in a
+// queue.RetryableError; 4xx errors fail the job immediately.
+func classifyErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	var apiError *client.ApiError
+	if errors.As(err, &apiError) {
+		if apiError.StatusCode == http.StatusTooManyRequests || apiError.StatusCode >= http.StatusInternalServerError {
+			return &queue.RetryableError{Err: err}
 		}
 	}
-	return false
+	return err
 }